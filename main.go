@@ -6,41 +6,94 @@ import (
 	"fmt"
     "io"
     "log"
+    "log/slog"
     "net/http"
+    "os"
     "strconv"
 	"time"
 
     "github.com/gin-gonic/gin"
     "task-api/models"
     "task-api/utils"
+    "task-api/utils/jobs"
+    "task-api/utils/metrics"
+    "task-api/utils/middleware"
 )
 
+// generateDummyJobsMaxAttempts bounds how many times in total a failing
+// generate_dummy job is attempted before it's given up on.
+const generateDummyJobsMaxAttempts = 5
+
+// metricsRefreshInterval controls how often the tasks_total/jobs_pending
+// gauges are refreshed from the database.
+const metricsRefreshInterval = 10 * time.Second
+
 func main() {
-    utils.InitDB()
-    defer utils.CloseDB()
+    store, err := utils.OpenFromEnv()
+    if err != nil {
+        log.Fatalf("Failed to open database: %v", err)
+    }
+    defer store.Close()
+
+    jobPool := jobs.NewPool(store, 4, generateDummyJobsMaxAttempts)
+    jobPool.RegisterHandler("generate_dummy", func(payload []byte) error {
+        var input struct {
+            Count int `json:"count"`
+        }
+        if err := json.Unmarshal(payload, &input); err != nil {
+            return err
+        }
+        return store.GenerateDummyTasks(input.Count)
+    })
+    jobPool.Start()
+    defer jobPool.Stop()
+
+    logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 
-    r := gin.Default()
+    go refreshMetrics(store)
 
-    // GET /tasks - List all tasks
+    r := gin.New()
+    // Recovery goes last so it's innermost: Logger and Metrics wrap it and
+    // still run their post-Next code (the access log line, the latency
+    // observation) when a downstream handler panics and Recovery stops the
+    // unwind, instead of it being swallowed along with the panic.
+    r.Use(middleware.RequestID(), middleware.Logger(logger), middleware.Metrics(), middleware.Recovery(logger))
+
+    r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+    // GET /tasks - List all tasks, optionally filtered by status, due date or a text search
     r.GET("/tasks", func(c *gin.Context) {
-		pageStr := c.DefaultQuery("page", "1") 
-		limitStr := c.DefaultQuery("limit", "10") 
-	
+		pageStr := c.DefaultQuery("page", "1")
+		limitStr := c.DefaultQuery("limit", "10")
+
 		page, err := strconv.Atoi(pageStr)
 		if err != nil || page <= 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page value"})
 			return
 		}
-	
+
 		limit, err := strconv.Atoi(limitStr)
 		if err != nil || limit <= 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit value"})
 			return
 		}
-	
+
 		offset := (page - 1) * limit
-	
-		tasks, totalCount, err := utils.GetPaginatedTasks(offset, limit)
+
+		filter := utils.TaskFilter{
+			Status: c.Query("status"),
+			Query:  c.Query("q"),
+		}
+		if dueBeforeStr := c.Query("dueBefore"); dueBeforeStr != "" {
+			dueBefore, err := time.Parse(time.RFC3339, dueBeforeStr)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dueBefore value, expected RFC3339"})
+				return
+			}
+			filter.DueBefore = &dueBefore
+		}
+
+		tasks, totalCount, err := store.GetFilteredTasks(offset, limit, filter)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks"})
 			return
@@ -58,40 +111,27 @@ func main() {
     r.POST("/tasks", func(c *gin.Context) {
 		body, err := c.GetRawData()
 		if err != nil {
-			log.Printf("Failed to read request body: %v", err)
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 			return
 		}
-		log.Printf("Received payload: %s", string(body))
-	
+
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
-	
+
 		var singleTask models.Task
 		var multipleTasks []models.Task
-	
+
 		if err := json.Unmarshal(body, &multipleTasks); err == nil && len(multipleTasks) > 0 {
-			log.Println("Decoded as an array of tasks")
 			for _, task := range multipleTasks {
-				if task.Title == "" || task.Position <= 0 {
-					c.JSON(http.StatusBadRequest, gin.H{"error": "Title and position are required for all tasks"})
-					return
-				}
-	
-				exists, err := utils.CheckPositionExists(task.Position)
-				if err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check position existence"})
-					return
-				}
-				if exists {
-					c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Position %d already exists", task.Position)})
+				if task.Title == "" {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Title is required for all tasks"})
 					return
 				}
 			}
-	
-			// Add multiple tasks
+
+			// Add multiple tasks, appended in the order given
 			var createdTasks []models.Task
 			for _, task := range multipleTasks {
-				newTask, err := utils.AddTask(task.Title, task.Description, task.Position)
+				newTask, err := store.AddTask(task.Title, task.Description, task.Tags)
 				if err != nil {
 					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add tasks"})
 					return
@@ -101,26 +141,14 @@ func main() {
 			c.JSON(http.StatusCreated, createdTasks)
 			return
 		}
-	
-		if err := json.Unmarshal(body, &singleTask); err == nil {
-			log.Println("Decoded as a single task")
 
-			if singleTask.Title == "" || singleTask.Position <= 0 {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Title and position are required"})
-				return
-			}
-	
-			exists, err := utils.CheckPositionExists(singleTask.Position)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check position existence"})
-				return
-			}
-			if exists {
-				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Position %d already exists", singleTask.Position)})
+		if err := json.Unmarshal(body, &singleTask); err == nil {
+			if singleTask.Title == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Title is required"})
 				return
 			}
-	
-			task, err := utils.AddTask(singleTask.Title, singleTask.Description, singleTask.Position)
+
+			task, err := store.AddTask(singleTask.Title, singleTask.Description, singleTask.Tags)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add task"})
 				return
@@ -132,26 +160,93 @@ func main() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 	})
 
-	// POST /tasks/generate?count=? - Generate tasks
+	// POST /tasks/import - Bulk import tasks from JSON, text/csv, or plain "#tag title: description" lines
+	r.POST("/tasks/import", func(c *gin.Context) {
+		count, err := store.ImportTasks(c.Request.Body, c.ContentType())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Failed to import tasks: %v", err)})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"message": fmt.Sprintf("Imported %d tasks", count)})
+	})
+
+	// GET /tasks/export?format=json|csv|text - Stream all tasks as JSON, CSV or plain text
+	r.GET("/tasks/export", func(c *gin.Context) {
+		format := c.DefaultQuery("format", "text")
+		switch format {
+		case "json":
+			c.Header("Content-Type", "application/json")
+		case "csv":
+			c.Header("Content-Type", "text/csv")
+		case "text":
+			c.Header("Content-Type", "text/plain")
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected json, csv or text"})
+			return
+		}
+
+		c.Status(http.StatusOK)
+		if err := store.ExportTasks(c.Writer, format); err != nil {
+			logger.Error("failed to export tasks", "error", err, "requestId", middleware.RequestIDFromContext(c))
+		}
+	})
+
+	// POST /tasks/generate?count=? - Enqueue a background job to generate tasks
 	r.POST("/tasks/generate", func(c *gin.Context) {
 		countStr := c.Query("count")
 		if countStr == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Count parameter is required"})
 			return
 		}
-	
+
 		count, err := strconv.Atoi(countStr)
 		if err != nil || count <= 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid count value"})
 			return
 		}
-	
-		err = utils.GenerateDummyTasks(count)
+
+		payload, err := json.Marshal(gin.H{"count": count})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build job payload"})
+			return
+		}
+
+		job, err := jobs.Enqueue(store, "generate_dummy", payload)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enqueue generate job"})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"jobId": job.ID, "message": fmt.Sprintf("Queued generation of %d dummy tasks", count)})
+	})
+
+	// GET /jobs/:id - Fetch a single job's status
+	r.GET("/jobs/:id", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+			return
+		}
+
+		job, err := jobs.GetJob(store, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job"})
+			return
+		}
+		if job == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	})
+
+	// GET /jobs?status=? - List jobs, optionally filtered by status
+	r.GET("/jobs", func(c *gin.Context) {
+		list, err := jobs.ListJobs(store, c.Query("status"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate dummy tasks"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch jobs"})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Successfully generated %d dummy tasks", count)})
+		c.JSON(http.StatusOK, gin.H{"jobs": list})
 	})
 
     // PUT /tasks/:id - Update an existing task
@@ -162,7 +257,7 @@ func main() {
 			return
 		}
 	
-		exists, err := utils.CheckTaskExists(id)
+		exists, err := store.CheckTaskExists(id)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check task existence"})
 			return
@@ -175,14 +270,13 @@ func main() {
 		var input struct {
 			Title       string `json:"title"`
 			Description string `json:"description"`
-			Position    int    `json:"position"`
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 			return
 		}
-	
-		err = utils.UpdateTask(id, input.Title, input.Description, input.Position)
+
+		err = store.UpdateTask(id, input.Title, input.Description)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task"})
 			return
@@ -198,7 +292,7 @@ func main() {
 			return
 		}
 	
-		exists, err := utils.CheckTaskExists(id)
+		exists, err := store.CheckTaskExists(id)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check task existence"})
 			return
@@ -208,7 +302,7 @@ func main() {
 			return
 		}
 	
-		err = utils.DeleteTask(id)
+		err = store.DeleteTask(id)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task"})
 			return
@@ -218,7 +312,7 @@ func main() {
 
 	// DELETE /tasks/:id - Delete all tasks
 	r.DELETE("/tasks", func(c *gin.Context) {
-		err := utils.DeleteAllTasks()
+		err := store.DeleteAllTasks()
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete all tasks"})
 			return
@@ -226,60 +320,208 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{"message": "All tasks deleted successfully"})
 	})
 
-    // PATCH /tasks/reorder - Reorder tasks
+    // PATCH /tasks/reorder - Reorder tasks; body is task IDs in their new order
     r.PATCH("/tasks/reorder", func(c *gin.Context) {
-		var updatedTasks []struct {
-			ID       int `json:"id"`
-			Position int `json:"position"`
-		}
-		if err := c.ShouldBindJSON(&updatedTasks); err != nil {
+		var ids []int
+		if err := c.ShouldBindJSON(&ids); err != nil || len(ids) == 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 			return
 		}
-	
-		for _, task := range updatedTasks {
-			if task.ID == 0 || task.Position <= 0 {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "ID and position are required for all tasks"})
-				return
-			}
-	
-			exists, err := utils.CheckTaskExists(task.ID)
+
+		for _, id := range ids {
+			exists, err := store.CheckTaskExists(id)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check task existence"})
 				return
 			}
 			if !exists {
-				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Task with ID %d not found", task.ID)})
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Task with ID %d not found", id)})
 				return
 			}
 		}
-	
-		tx, err := utils.GetDB().Begin()
+
+		if err := store.ReorderTasks(ids); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder tasks"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Tasks reordered successfully"})
+	})
+
+	// PATCH /tasks/:id/move - Move a single task between two neighbors
+	r.PATCH("/tasks/:id/move", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+			return
+		}
+
+		exists, err := store.CheckTaskExists(id)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check task existence"})
 			return
 		}
-		defer tx.Rollback()
-	
-		query := "UPDATE tasks SET position = ?, updated_at = ? WHERE id = ?"
-		for _, task := range updatedTasks {
-			updatedAt := time.Now()
-			_, err := tx.Exec(query, task.Position, updatedAt, task.ID)
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+
+		var input struct {
+			BeforeID *int `json:"beforeId"`
+			AfterID  *int `json:"afterId"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		for _, neighborID := range []*int{input.BeforeID, input.AfterID} {
+			if neighborID == nil {
+				continue
+			}
+			exists, err := store.CheckTaskExists(*neighborID)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to update task with ID %d", task.ID)})
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check task existence"})
+				return
+			}
+			if !exists {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Task with ID %d not found", *neighborID)})
 				return
 			}
 		}
-	
-		err = tx.Commit()
+
+		if err := store.MoveTask(id, input.BeforeID, input.AfterID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move task"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Task moved successfully"})
+	})
+
+	// PATCH /tasks/:id/status - Transition a task to an arbitrary legal status
+	r.PATCH("/tasks/:id/status", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+			return
+		}
+
+		var input struct {
+			Status string `json:"status"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil || input.Status == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Status is required"})
+			return
+		}
+
+		exists, err := store.CheckTaskExists(id)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check task existence"})
 			return
 		}
-	
-		c.JSON(http.StatusOK, gin.H{"message": "Tasks reordered successfully"})
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+
+		if err := store.SetTaskStatus(id, input.Status); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Task status updated successfully"})
+	})
+
+	// PATCH /tasks/:id/pause - Pause an active task
+	r.PATCH("/tasks/:id/pause", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+			return
+		}
+
+		exists, err := store.CheckTaskExists(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check task existence"})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+
+		if err := store.PauseTask(id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Task paused successfully"})
+	})
+
+	// PATCH /tasks/:id/resume - Resume a paused task
+	r.PATCH("/tasks/:id/resume", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+			return
+		}
+
+		exists, err := store.CheckTaskExists(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check task existence"})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+
+		if err := store.ResumeTask(id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Task resumed successfully"})
+	})
+
+	// PATCH /tasks/:id/complete - Mark a task as completed
+	r.PATCH("/tasks/:id/complete", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+			return
+		}
+
+		exists, err := store.CheckTaskExists(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check task existence"})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+
+		if err := store.CompleteTask(id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Task completed successfully"})
 	})
 
     // Start the server
     r.Run(":3000")
+}
+
+// refreshMetrics periodically recomputes the tasks_total and jobs_pending
+// gauges from the database, since neither is cheap to keep in sync from
+// every call site that changes them.
+func refreshMetrics(store utils.Store) {
+    ticker := time.NewTicker(metricsRefreshInterval)
+    defer ticker.Stop()
+
+    for range ticker.C {
+        if count, err := store.CountTasks(); err == nil {
+            metrics.SetTasksTotal(float64(count))
+        }
+        if count, err := jobs.CountByStatus(store, jobs.StatusPending); err == nil {
+            metrics.SetJobsPending(float64(count))
+        }
+    }
 }
\ No newline at end of file
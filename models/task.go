@@ -2,11 +2,28 @@ package models
 
 import "time"
 
+// Task lifecycle states. A task starts as pending, becomes active once
+// work begins, can be paused/resumed any number of times, and eventually
+// ends up completed or archived.
+const (
+	StatusPending   = "pending"
+	StatusActive    = "active"
+	StatusPaused    = "paused"
+	StatusCompleted = "completed"
+	StatusArchived  = "archived"
+)
+
 type Task struct {
-    ID          int       `json:"id"`
-    Title       string    `json:"title"`
-    Description string    `json:"description"`
-    Position    int       `json:"position"`
-    CreatedAt   time.Time `json:"createdAt"`
-    UpdatedAt   time.Time `json:"updatedAt"`
-}
\ No newline at end of file
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Position    string     `json:"position"`
+	Tags        []string   `json:"tags,omitempty"`
+	Status      string     `json:"status"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	PausedAt    *time.Time `json:"pausedAt,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
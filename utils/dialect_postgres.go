@@ -0,0 +1,83 @@
+package utils
+
+import (
+    "database/sql"
+    "strconv"
+    "strings"
+
+    "task-api/models"
+)
+
+// postgresDialect backs a Store with lib/pq. It needs "$1", "$2", ...
+// placeholders, has no LastInsertId support (so inserts get a RETURNING
+// id clause instead), and its schema is created in one shot rather than
+// migrated incrementally.
+type postgresDialect struct{}
+
+func (postgresDialect) driver() Driver {
+    return DriverPostgres
+}
+
+// rebind rewrites "?" placeholders into "$1", "$2", ... for Postgres.
+func (postgresDialect) rebind(query string) string {
+    var b strings.Builder
+    n := 0
+    for _, r := range query {
+        if r == '?' {
+            n++
+            b.WriteByte('$')
+            b.WriteString(strconv.Itoa(n))
+            continue
+        }
+        b.WriteRune(r)
+    }
+    return b.String()
+}
+
+func (postgresDialect) insertReturningID(db *sql.DB, query string, args ...interface{}) (int, error) {
+    var id int
+    err := db.QueryRow(query+" RETURNING id", args...).Scan(&id)
+    return id, err
+}
+
+// tuneForBulkInsert is a no-op: the SQLite PRAGMA tuning GenerateDummyTasks
+// needs has no Postgres equivalent worth reaching for here.
+func (postgresDialect) tuneForBulkInsert(db *sql.DB) error {
+    return nil
+}
+
+func (postgresDialect) migrate(db *sql.DB) error {
+    schema := `
+	CREATE TABLE IF NOT EXISTS tasks (
+		id SERIAL PRIMARY KEY,
+		title TEXT NOT NULL,
+		description TEXT,
+		position TEXT NOT NULL,
+		tags TEXT,
+		status TEXT NOT NULL DEFAULT '` + models.StatusPending + `',
+		started_at TIMESTAMPTZ,
+		paused_at TIMESTAMPTZ,
+		completed_at TIMESTAMPTZ,
+		due_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_position ON tasks(position);
+	CREATE INDEX IF NOT EXISTS idx_status ON tasks(status);
+
+	CREATE TABLE IF NOT EXISTS jobs (
+		id SERIAL PRIMARY KEY,
+		kind TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT,
+		next_attempt_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status);
+	`
+    _, err := db.Exec(schema)
+    return err
+}
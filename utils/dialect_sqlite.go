@@ -0,0 +1,193 @@
+package utils
+
+import (
+    "database/sql"
+    "strings"
+
+    "task-api/models"
+    "task-api/utils/rank"
+)
+
+// sqliteDialect backs a Store with modernc.org/sqlite. It uses "?"
+// placeholders natively and supports LastInsertId, but needs its own
+// migration path, since SQLite has no "ADD COLUMN IF NOT EXISTS" and its
+// schema started life with an integer position column.
+type sqliteDialect struct{}
+
+func (sqliteDialect) driver() Driver {
+    return DriverSQLite
+}
+
+func (sqliteDialect) rebind(query string) string {
+    return query
+}
+
+func (sqliteDialect) insertReturningID(db *sql.DB, query string, args ...interface{}) (int, error) {
+    result, err := db.Exec(query, args...)
+    if err != nil {
+        return 0, err
+    }
+    id, err := result.LastInsertId()
+    return int(id), err
+}
+
+// tuneForBulkInsert relaxes durability ahead of a bulk-insert run (e.g.
+// GenerateDummyTasks): fsyncs and the rollback journal are the dominant
+// cost of inserting many rows one at a time. The settings are not restored
+// once the run is over.
+func (sqliteDialect) tuneForBulkInsert(db *sql.DB) error {
+    if _, err := db.Exec("PRAGMA synchronous = OFF"); err != nil {
+        return err
+    }
+    if _, err := db.Exec("PRAGMA journal_mode = MEMORY"); err != nil {
+        return err
+    }
+    if _, err := db.Exec("PRAGMA cache_size = -10000"); err != nil { // Allocate 10MB of cache
+        return err
+    }
+    return nil
+}
+
+// migrate creates the schema if it doesn't exist yet and brings older
+// databases up to date.
+func (sqliteDialect) migrate(db *sql.DB) error {
+    schema := `
+	CREATE TABLE IF NOT EXISTS tasks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, -- Ensure AUTOINCREMENT is used
+		title TEXT NOT NULL,
+		description TEXT,
+		position TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_position ON tasks(position);
+	`
+    if _, err := db.Exec(schema); err != nil {
+        return err
+    }
+
+    if err := migrateSQLiteLifecycleColumns(db); err != nil {
+        return err
+    }
+    if err := migrateSQLitePositionToRank(db); err != nil {
+        return err
+    }
+
+    jobsSchema := `
+	CREATE TABLE IF NOT EXISTS jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT,
+		next_attempt_at DATETIME,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status);
+	`
+    _, err := db.Exec(jobsSchema)
+    return err
+}
+
+// migrateSQLiteLifecycleColumns adds the task lifecycle columns to
+// pre-existing databases. SQLite has no "ADD COLUMN IF NOT EXISTS", so each
+// column is added individually and a "duplicate column name" error is
+// treated as already-migrated rather than fatal.
+func migrateSQLiteLifecycleColumns(db *sql.DB) error {
+    columns := []string{
+        "status TEXT NOT NULL DEFAULT '" + models.StatusPending + "'",
+        "started_at DATETIME",
+        "paused_at DATETIME",
+        "completed_at DATETIME",
+        "due_at DATETIME",
+        "tags TEXT",
+    }
+
+    for _, col := range columns {
+        _, err := db.Exec("ALTER TABLE tasks ADD COLUMN " + col)
+        if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+            return err
+        }
+    }
+
+    _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_status ON tasks(status)")
+    return err
+}
+
+// migrateSQLitePositionToRank converts a pre-existing integer position
+// column into the TEXT rank keys used by rank.Between, preserving the
+// current ordering. It's a no-op once the column is already TEXT.
+func migrateSQLitePositionToRank(db *sql.DB) error {
+    rows, err := db.Query("PRAGMA table_info(tasks)")
+    if err != nil {
+        return err
+    }
+    var positionType string
+    for rows.Next() {
+        var cid int
+        var name, colType string
+        var notNull, pk int
+        var dfltValue sql.NullString
+        if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+            rows.Close()
+            return err
+        }
+        if name == "position" {
+            positionType = strings.ToUpper(colType)
+        }
+    }
+    rows.Close()
+
+    if positionType != "INTEGER" {
+        return nil
+    }
+
+    idRows, err := db.Query("SELECT id FROM tasks ORDER BY position ASC, id ASC")
+    if err != nil {
+        return err
+    }
+    var ids []int
+    for idRows.Next() {
+        var id int
+        if err := idRows.Scan(&id); err != nil {
+            idRows.Close()
+            return err
+        }
+        ids = append(ids, id)
+    }
+    idRows.Close()
+
+    if _, err := db.Exec("ALTER TABLE tasks ADD COLUMN position_rank TEXT"); err != nil {
+        return err
+    }
+
+    keys := rank.Rebalance(len(ids))
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    for i, id := range ids {
+        if _, err := tx.Exec("UPDATE tasks SET position_rank = ? WHERE id = ?", keys[i], id); err != nil {
+            tx.Rollback()
+            return err
+        }
+    }
+    if err := tx.Commit(); err != nil {
+        return err
+    }
+
+    statements := []string{
+        "DROP INDEX IF EXISTS idx_position",
+        "ALTER TABLE tasks DROP COLUMN position",
+        "ALTER TABLE tasks RENAME COLUMN position_rank TO position",
+        "CREATE INDEX IF NOT EXISTS idx_position ON tasks(position)",
+    }
+    for _, stmt := range statements {
+        if _, err := db.Exec(stmt); err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,308 @@
+package utils
+
+import (
+    "bufio"
+    "database/sql"
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+    "time"
+
+    "task-api/models"
+    "task-api/utils/rank"
+)
+
+// insertFunc persists one imported task, reporting only whether it failed.
+type insertFunc func(title, description string, tags []string) error
+
+// ImportTasks parses body according to contentType (JSON, text/csv, or the
+// plain "#tag title: description" line format otherwise) and inserts every
+// task it finds in a single transaction, so a bad row rolls back the whole
+// import. Returns the number of tasks inserted.
+func (s *sqlStore) ImportTasks(body io.Reader, contentType string) (int, error) {
+    tx, err := s.Begin()
+    if err != nil {
+        return 0, err
+    }
+    committed := false
+    defer func() {
+        if !committed {
+            tx.Rollback()
+        }
+    }()
+
+    var lastPosition string
+    err = s.TxQueryRow(tx, "SELECT position FROM tasks ORDER BY position DESC LIMIT 1").Scan(&lastPosition)
+    if err != nil && err != sql.ErrNoRows {
+        return 0, err
+    }
+
+    count := 0
+    insert := func(title, description string, tags []string) error {
+        if title == "" {
+            return nil
+        }
+        lastPosition = rank.Between(lastPosition, "")
+
+        encodedTags, err := encodeTags(tags)
+        if err != nil {
+            return err
+        }
+
+        now := time.Now()
+        _, err = s.TxExec(tx,
+            `INSERT INTO tasks (title, description, position, tags, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+            title, description, lastPosition, encodedTags, models.StatusPending, now, now,
+        )
+        if err != nil {
+            return err
+        }
+        count++
+        return nil
+    }
+
+    switch {
+    case strings.Contains(contentType, "text/csv"):
+        err = importCSV(body, insert)
+    case strings.Contains(contentType, "application/json"):
+        err = importJSON(body, insert)
+    default:
+        err = importPlainText(body, insert)
+    }
+    if err != nil {
+        return 0, err
+    }
+
+    if len(lastPosition) > rank.MaxKeyLength {
+        if err := s.rebalancePositions(tx); err != nil {
+            return 0, err
+        }
+    }
+
+    if err := tx.Commit(); err != nil {
+        return 0, err
+    }
+    committed = true
+    return count, nil
+}
+
+// importPlainText streams the body line by line rather than buffering it
+// whole. Each line is "#tag1 #tag2 title: description"; leading #tag tokens
+// are optional and collected into the task's tags.
+func importPlainText(body io.Reader, insert insertFunc) error {
+    scanner := bufio.NewScanner(body)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" {
+            continue
+        }
+
+        var tags []string
+        for strings.HasPrefix(line, "#") {
+            parts := strings.SplitN(line, " ", 2)
+            tags = append(tags, strings.TrimPrefix(parts[0], "#"))
+            if len(parts) == 1 {
+                line = ""
+                break
+            }
+            line = strings.TrimSpace(parts[1])
+        }
+
+        title, description := line, ""
+        if idx := strings.Index(line, ":"); idx != -1 {
+            title = strings.TrimSpace(line[:idx])
+            description = strings.TrimSpace(line[idx+1:])
+        }
+
+        if err := insert(title, description, tags); err != nil {
+            return err
+        }
+    }
+    return scanner.Err()
+}
+
+// importCSV streams rows with csv.Reader.Read rather than ReadAll. The
+// header row must include a "title" column; "description" and "tags"
+// (semicolon-separated) columns are optional.
+func importCSV(body io.Reader, insert insertFunc) error {
+    reader := csv.NewReader(body)
+    reader.FieldsPerRecord = -1
+
+    header, err := reader.Read()
+    if err == io.EOF {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+
+    columns := make(map[string]int, len(header))
+    for i, name := range header {
+        columns[strings.ToLower(strings.TrimSpace(name))] = i
+    }
+    titleCol, ok := columns["title"]
+    if !ok {
+        return fmt.Errorf("csv import: header is missing a \"title\" column")
+    }
+    descCol, hasDesc := columns["description"]
+    tagsCol, hasTags := columns["tags"]
+
+    for {
+        record, err := reader.Read()
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+
+        if titleCol >= len(record) {
+            return fmt.Errorf("csv import: malformed row, missing \"title\" column")
+        }
+        title := strings.TrimSpace(record[titleCol])
+        description := ""
+        if hasDesc && descCol < len(record) {
+            description = record[descCol]
+        }
+        var tags []string
+        if hasTags && tagsCol < len(record) && record[tagsCol] != "" {
+            tags = strings.Split(record[tagsCol], ";")
+        }
+
+        if err := insert(title, description, tags); err != nil {
+            return err
+        }
+    }
+}
+
+// importJSON decodes a JSON array token by token via json.Decoder, so the
+// body is streamed rather than buffered and unmarshaled whole.
+func importJSON(body io.Reader, insert insertFunc) error {
+    dec := json.NewDecoder(body)
+
+    tok, err := dec.Token()
+    if err != nil {
+        return err
+    }
+    if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+        return fmt.Errorf("json import: expected a JSON array of tasks")
+    }
+
+    for dec.More() {
+        var t struct {
+            Title       string   `json:"title"`
+            Description string   `json:"description"`
+            Tags        []string `json:"tags"`
+        }
+        if err := dec.Decode(&t); err != nil {
+            return err
+        }
+        if err := insert(t.Title, t.Description, t.Tags); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// flusher is satisfied by http.ResponseWriter; kept narrow so this package
+// doesn't need to import net/http.
+type flusher interface {
+    Flush()
+}
+
+// ExportTasks streams every task to w in the given format ("json", "csv",
+// or plain text), flushing after each row so large exports don't buffer in
+// memory.
+func (s *sqlStore) ExportTasks(w io.Writer, format string) error {
+    rows, err := s.Query("SELECT " + taskColumns + " FROM tasks ORDER BY position ASC")
+    if err != nil {
+        return err
+    }
+    defer rows.Close()
+
+    var flush func()
+    if f, ok := w.(flusher); ok {
+        flush = f.Flush
+    }
+
+    if format == "json" {
+        if _, err := io.WriteString(w, "["); err != nil {
+            return err
+        }
+        enc := json.NewEncoder(w)
+        first := true
+        for rows.Next() {
+            var task models.Task
+            if err := scanTask(rows, &task); err != nil {
+                return err
+            }
+            if !first {
+                if _, err := io.WriteString(w, ","); err != nil {
+                    return err
+                }
+            }
+            first = false
+            if err := enc.Encode(&task); err != nil {
+                return err
+            }
+            if flush != nil {
+                flush()
+            }
+        }
+        if err := rows.Err(); err != nil {
+            return err
+        }
+        _, err := io.WriteString(w, "]")
+        return err
+    }
+
+    if format == "csv" {
+        csvWriter := csv.NewWriter(w)
+        if err := csvWriter.Write([]string{"id", "title", "description", "tags", "status", "position", "createdAt", "updatedAt"}); err != nil {
+            return err
+        }
+        for rows.Next() {
+            var task models.Task
+            if err := scanTask(rows, &task); err != nil {
+                return err
+            }
+            record := []string{
+                strconv.Itoa(task.ID), task.Title, task.Description, strings.Join(task.Tags, ";"),
+                task.Status, task.Position, task.CreatedAt.Format(time.RFC3339), task.UpdatedAt.Format(time.RFC3339),
+            }
+            if err := csvWriter.Write(record); err != nil {
+                return err
+            }
+            csvWriter.Flush()
+            if err := csvWriter.Error(); err != nil {
+                return err
+            }
+            if flush != nil {
+                flush()
+            }
+        }
+        return rows.Err()
+    }
+
+    for rows.Next() {
+        var task models.Task
+        if err := scanTask(rows, &task); err != nil {
+            return err
+        }
+        var line strings.Builder
+        for _, tag := range task.Tags {
+            line.WriteString("#" + tag + " ")
+        }
+        line.WriteString(task.Title + ": " + task.Description + "\n")
+        if _, err := io.WriteString(w, line.String()); err != nil {
+            return err
+        }
+        if flush != nil {
+            flush()
+        }
+    }
+    return rows.Err()
+}
@@ -0,0 +1,281 @@
+// Package jobs implements a small persistent job queue used to move
+// long-running operations out of the HTTP request path. Jobs are
+// persisted to the jobs table so they survive a restart, and a pool of
+// worker goroutines leases them one at a time by flipping their status to
+// running inside a transaction, so two workers never pick up the same job.
+package jobs
+
+import (
+    "database/sql"
+    "fmt"
+    "log"
+    "time"
+
+    "task-api/utils"
+)
+
+const (
+    StatusPending   = "pending"
+    StatusRunning   = "running"
+    StatusCompleted = "completed"
+    StatusFailed    = "failed"
+)
+
+type Job struct {
+    ID            int        `json:"id"`
+    Kind          string     `json:"kind"`
+    Payload       string     `json:"payload"`
+    Status        string     `json:"status"`
+    Attempts      int        `json:"attempts"`
+    LastError     string     `json:"lastError,omitempty"`
+    NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+    CreatedAt     time.Time  `json:"createdAt"`
+    UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+const jobColumns = `id, kind, payload, status, attempts, last_error, next_attempt_at, created_at, updated_at`
+
+func scanJob(row interface{ Scan(dest ...interface{}) error }, job *Job) error {
+    var lastError sql.NullString
+    var nextAttempt sql.NullTime
+    if err := row.Scan(&job.ID, &job.Kind, &job.Payload, &job.Status, &job.Attempts, &lastError, &nextAttempt, &job.CreatedAt, &job.UpdatedAt); err != nil {
+        return err
+    }
+    job.LastError = lastError.String
+    if nextAttempt.Valid {
+        job.NextAttemptAt = &nextAttempt.Time
+    }
+    return nil
+}
+
+// Enqueue persists a new pending job of the given kind with a JSON payload.
+func Enqueue(store utils.Store, kind string, payload []byte) (*Job, error) {
+    now := time.Now()
+    id, err := store.InsertReturningID(
+        `INSERT INTO jobs (kind, payload, status, attempts, created_at, updated_at) VALUES (?, ?, ?, 0, ?, ?)`,
+        kind, string(payload), StatusPending, now, now,
+    )
+    if err != nil {
+        return nil, err
+    }
+    return GetJob(store, id)
+}
+
+// GetJob retrieves a job by ID, returning (nil, nil) if it doesn't exist.
+func GetJob(store utils.Store, id int) (*Job, error) {
+    var job Job
+    row := store.QueryRow("SELECT "+jobColumns+" FROM jobs WHERE id = ?", id)
+    if err := scanJob(row, &job); err != nil {
+        if err == sql.ErrNoRows {
+            return nil, nil
+        }
+        return nil, err
+    }
+    return &job, nil
+}
+
+// ListJobs retrieves all jobs, optionally filtered by status, newest first.
+func ListJobs(store utils.Store, status string) ([]Job, error) {
+    query := "SELECT " + jobColumns + " FROM jobs"
+    var args []interface{}
+    if status != "" {
+        query += " WHERE status = ?"
+        args = append(args, status)
+    }
+    query += " ORDER BY id DESC"
+
+    rows, err := store.Query(query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var result []Job
+    for rows.Next() {
+        var job Job
+        if err := scanJob(rows, &job); err != nil {
+            return nil, err
+        }
+        result = append(result, job)
+    }
+    return result, nil
+}
+
+// CountByStatus returns the number of jobs with the given status, for
+// metrics reporting.
+func CountByStatus(store utils.Store, status string) (int, error) {
+    var count int
+    err := store.QueryRow("SELECT COUNT(*) FROM jobs WHERE status = ?", status).Scan(&count)
+    return count, err
+}
+
+// HandlerFunc processes a single job's payload. An error triggers a retry
+// with exponential backoff, up to the pool's configured max attempts.
+type HandlerFunc func(payload []byte) error
+
+// Pool runs a fixed number of worker goroutines that lease and process
+// pending jobs.
+type Pool struct {
+    store        utils.Store
+    workers      int
+    maxAttempts  int
+    pollInterval time.Duration
+    handlers     map[string]HandlerFunc
+    stop         chan struct{}
+}
+
+// NewPool creates a worker pool backed by store. workers controls how many
+// goroutines run concurrently; maxAttempts controls how many times in total
+// a failing job is attempted (the first run included) before it is marked
+// failed.
+func NewPool(store utils.Store, workers, maxAttempts int) *Pool {
+    return &Pool{
+        store:        store,
+        workers:      workers,
+        maxAttempts:  maxAttempts,
+        pollInterval: 500 * time.Millisecond,
+        handlers:     make(map[string]HandlerFunc),
+        stop:         make(chan struct{}),
+    }
+}
+
+// RegisterHandler associates a job kind with the function that processes it.
+func (p *Pool) RegisterHandler(kind string, fn HandlerFunc) {
+    p.handlers[kind] = fn
+}
+
+// Start launches the worker goroutines. It does not block.
+func (p *Pool) Start() {
+    for i := 0; i < p.workers; i++ {
+        go p.runWorker(i)
+    }
+}
+
+// Stop signals all workers to exit after their current poll.
+func (p *Pool) Stop() {
+    close(p.stop)
+}
+
+func (p *Pool) runWorker(id int) {
+    ticker := time.NewTicker(p.pollInterval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-p.stop:
+            return
+        case <-ticker.C:
+            if err := p.tick(); err != nil {
+                log.Printf("jobs: worker %d: %v", id, err)
+            }
+        }
+    }
+}
+
+func (p *Pool) tick() error {
+    job, err := p.lease()
+    if err != nil {
+        return fmt.Errorf("failed to lease job: %w", err)
+    }
+    if job == nil {
+        return nil
+    }
+
+    handler, ok := p.handlers[job.Kind]
+    if !ok {
+        p.fail(job, fmt.Errorf("no handler registered for job kind %q", job.Kind))
+        return nil
+    }
+
+    if err := handler([]byte(job.Payload)); err != nil {
+        p.fail(job, err)
+        return nil
+    }
+
+    p.complete(job)
+    return nil
+}
+
+// lease claims the oldest eligible pending job by updating its status to
+// running inside a transaction, so multiple workers don't double-pick it.
+func (p *Pool) lease() (*Job, error) {
+    tx, err := p.store.Begin()
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback()
+
+    now := time.Now()
+    var id int
+    err = p.store.TxQueryRow(tx,
+        `SELECT id FROM jobs WHERE status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?) ORDER BY id ASC LIMIT 1`,
+        StatusPending, now,
+    ).Scan(&id)
+    if err == sql.ErrNoRows {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    res, err := p.store.TxExec(tx, `UPDATE jobs SET status = ?, updated_at = ? WHERE id = ? AND status = ?`, StatusRunning, now, id, StatusPending)
+    if err != nil {
+        return nil, err
+    }
+    claimed, err := res.RowsAffected()
+    if err != nil {
+        return nil, err
+    }
+    if claimed == 0 {
+        // Another worker claimed this job between our SELECT and UPDATE;
+        // leave it for that worker instead of reading back its lease.
+        return nil, nil
+    }
+
+    var job Job
+    if err := scanJob(p.store.TxQueryRow(tx, "SELECT "+jobColumns+" FROM jobs WHERE id = ?", id), &job); err != nil {
+        return nil, err
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, err
+    }
+    return &job, nil
+}
+
+func (p *Pool) complete(job *Job) {
+    now := time.Now()
+    _, err := p.store.Exec(`UPDATE jobs SET status = ?, updated_at = ? WHERE id = ?`, StatusCompleted, now, job.ID)
+    if err != nil {
+        log.Printf("jobs: failed to mark job %d completed: %v", job.ID, err)
+    }
+}
+
+// fail records a job failure. If attempts remain, the job goes back to
+// pending with an exponential backoff on next_attempt_at; otherwise it is
+// marked failed for good.
+func (p *Pool) fail(job *Job, jobErr error) {
+    attempts := job.Attempts + 1
+    now := time.Now()
+
+    if attempts >= p.maxAttempts {
+        _, err := p.store.Exec(
+            `UPDATE jobs SET status = ?, attempts = ?, last_error = ?, updated_at = ? WHERE id = ?`,
+            StatusFailed, attempts, jobErr.Error(), now, job.ID,
+        )
+        if err != nil {
+            log.Printf("jobs: failed to mark job %d failed: %v", job.ID, err)
+        }
+        return
+    }
+
+    backoff := time.Duration(1<<uint(attempts)) * time.Second
+    nextAttempt := now.Add(backoff)
+    _, err := p.store.Exec(
+        `UPDATE jobs SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ?, updated_at = ? WHERE id = ?`,
+        StatusPending, attempts, jobErr.Error(), nextAttempt, now, job.ID,
+    )
+    if err != nil {
+        log.Printf("jobs: failed to reschedule job %d: %v", job.ID, err)
+    }
+}
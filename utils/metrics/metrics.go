@@ -0,0 +1,58 @@
+// Package metrics holds the process's Prometheus collectors: HTTP request
+// counters/histograms plus a couple of point-in-time gauges for the task
+// and job tables.
+package metrics
+
+import (
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+    "github.com/prometheus/client_golang/prometheus/promauto"
+    "github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+    requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+        Name: "http_requests_total",
+        Help: "Total number of HTTP requests processed, labeled by method, route and status.",
+    }, []string{"method", "route", "status"})
+
+    requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+        Name:    "http_request_duration_seconds",
+        Help:    "HTTP request latency in seconds, labeled by method and route.",
+        Buckets: prometheus.DefBuckets,
+    }, []string{"method", "route"})
+
+    tasksTotal = promauto.NewGauge(prometheus.GaugeOpts{
+        Name: "tasks_total",
+        Help: "Current number of tasks in the tasks table.",
+    })
+
+    jobsPending = promauto.NewGauge(prometheus.GaugeOpts{
+        Name: "jobs_pending",
+        Help: "Current number of jobs in the jobs table with status pending.",
+    })
+)
+
+// Observe records one completed request's status and latency.
+func Observe(method, route string, status int, duration time.Duration) {
+    requestsTotal.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+    requestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// SetTasksTotal updates the tasks_total gauge.
+func SetTasksTotal(n float64) {
+    tasksTotal.Set(n)
+}
+
+// SetJobsPending updates the jobs_pending gauge.
+func SetJobsPending(n float64) {
+    jobsPending.Set(n)
+}
+
+// Handler serves the Prometheus exposition format for scraping.
+func Handler() http.Handler {
+    return promhttp.Handler()
+}
@@ -0,0 +1,96 @@
+// Package middleware holds the Gin middleware chain shared by every route:
+// request ID propagation, structured JSON access logging, panic recovery,
+// and Prometheus request instrumentation.
+package middleware
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "log/slog"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "task-api/utils/metrics"
+)
+
+// RequestIDHeader is the header used to propagate/return a request ID.
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the gin context key the request ID is stored under.
+const requestIDKey = "requestID"
+
+// RequestID propagates an incoming X-Request-ID header, or generates a new
+// one if the client didn't send one, and echoes it back on the response.
+func RequestID() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        id := c.GetHeader(RequestIDHeader)
+        if id == "" {
+            id = newRequestID()
+        }
+        c.Set(requestIDKey, id)
+        c.Header(RequestIDHeader, id)
+        c.Next()
+    }
+}
+
+// RequestIDFromContext returns the current request's ID, or "" if the
+// RequestID middleware hasn't run.
+func RequestIDFromContext(c *gin.Context) string {
+    return c.GetString(requestIDKey)
+}
+
+func newRequestID() string {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        return "unknown"
+    }
+    return hex.EncodeToString(b)
+}
+
+// Logger emits one structured JSON log line per request via slog.
+func Logger(logger *slog.Logger) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        start := time.Now()
+        c.Next()
+
+        logger.Info("request",
+            "method", c.Request.Method,
+            "path", c.FullPath(),
+            "status", c.Writer.Status(),
+            "latencyMs", time.Since(start).Milliseconds(),
+            "bytes", c.Writer.Size(),
+            "requestId", c.GetString(requestIDKey),
+        )
+    }
+}
+
+// Recovery recovers from panics in downstream handlers and responds with a
+// JSON 500 instead of Gin's default HTML error page.
+func Recovery(logger *slog.Logger) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        defer func() {
+            if rec := recover(); rec != nil {
+                logger.Error("panic recovered", "error", rec, "requestId", c.GetString(requestIDKey))
+                c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+            }
+        }()
+        c.Next()
+    }
+}
+
+// Metrics records each request's status and latency against the route's
+// registered path pattern (so /tasks/:id doesn't explode cardinality).
+func Metrics() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        start := time.Now()
+        c.Next()
+
+        route := c.FullPath()
+        if route == "" {
+            route = "unmatched"
+        }
+        metrics.Observe(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+    }
+}
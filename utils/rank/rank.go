@@ -0,0 +1,108 @@
+// Package rank implements fractional indexing: string "rank" keys that sort
+// lexicographically in the order of the list they position, so reordering a
+// single row never requires rewriting the keys of its neighbors.
+package rank
+
+// alphabet is ordered so that its index order matches byte order, which lets
+// the database sort rank keys with a plain TEXT comparison.
+const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+const base = len(alphabet)
+
+// MaxKeyLength is the length past which keys produced by repeated Between
+// calls should be rebalanced back down with Rebalance.
+const MaxKeyLength = 12
+
+func digit(c byte) int {
+    for i := 0; i < base; i++ {
+        if alphabet[i] == c {
+            return i
+        }
+    }
+    return 0
+}
+
+// Between returns a rank key that sorts strictly between prev and next.
+// An empty prev means "no lower bound", an empty next means "no upper
+// bound"; both empty returns the midpoint of the whole key space.
+func Between(prev, next string) string {
+    if prev == "" && next == "" {
+        return string(alphabet[base/2])
+    }
+    if next == "" {
+        return prev + string(alphabet[base/2])
+    }
+    if prev == "" {
+        prev = string(alphabet[0])
+    }
+
+    p, n := prev, next
+    for len(p) < len(n) {
+        p += string(alphabet[0])
+    }
+    for len(n) < len(p) {
+        n += string(alphabet[0])
+    }
+
+    for i := 0; i < len(p); i++ {
+        pc, nc := digit(p[i]), digit(n[i])
+        if pc == nc {
+            continue
+        }
+        if nc-pc > 1 {
+            mid := pc + (nc-pc)/2
+            return p[:i] + string(alphabet[mid])
+        }
+        // neighbors are adjacent (e.g. "a" and "b"): keep the lower key's
+        // prefix and append a midpoint character after the rest of it
+        // ("a" -> "aV", "aZ" -> "aZV"), so the result stays above prev
+        rest := p[i+1:]
+        for len(rest) > 0 && rest[len(rest)-1] == alphabet[0] {
+            rest = rest[:len(rest)-1]
+        }
+        return p[:i+1] + Between(rest, "")
+    }
+
+    // p and n were equal after padding, which shouldn't happen for
+    // distinct, correctly ordered neighbors; fall back to appending.
+    return p + string(alphabet[base/2])
+}
+
+// Rebalance returns n rank keys, evenly spaced across the key space in
+// ascending order. Used to reassign every row's key when repeated Between
+// calls have made keys longer than MaxKeyLength.
+func Rebalance(n int) []string {
+    if n <= 0 {
+        return nil
+    }
+
+    digits := 1
+    capacity := base
+    for capacity < n+1 {
+        digits++
+        capacity *= base
+    }
+
+    step := capacity / (n + 1)
+    if step < 1 {
+        step = 1
+    }
+
+    keys := make([]string, n)
+    for i := 0; i < n; i++ {
+        v := (i + 1) * step
+        if v >= capacity {
+            v = capacity - 1
+        }
+        keys[i] = encode(v, digits)
+    }
+    return keys
+}
+
+func encode(v, digits int) string {
+    buf := make([]byte, digits)
+    for i := digits - 1; i >= 0; i-- {
+        buf[i] = alphabet[v%base]
+        v /= base
+    }
+    return string(buf)
+}
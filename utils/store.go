@@ -0,0 +1,179 @@
+package utils
+
+import (
+    "database/sql"
+    "io"
+    "log"
+    "os"
+
+    "task-api/models"
+
+    _ "github.com/lib/pq"
+    _ "modernc.org/sqlite"
+)
+
+// Driver identifies which database backend a Store talks to.
+type Driver string
+
+const (
+    DriverSQLite   Driver = "sqlite"
+    DriverPostgres Driver = "postgres"
+)
+
+// Store is the persistence boundary between the HTTP handlers/job workers
+// in main.go and the database. Callers depend on this interface rather
+// than a concrete type, so a handler or worker can't reach past it into
+// driver-specific details, and a test double can stand in for a real
+// database. sqlStore is the one implementation, parameterized by a
+// dialect; see dialect_sqlite.go and dialect_postgres.go.
+type Store interface {
+    // Low-level helpers used by packages (e.g. jobs) that persist their
+    // own tables against the same underlying database.
+    Exec(query string, args ...interface{}) (sql.Result, error)
+    Query(query string, args ...interface{}) (*sql.Rows, error)
+    QueryRow(query string, args ...interface{}) *sql.Row
+    Begin() (*sql.Tx, error)
+    TxExec(tx *sql.Tx, query string, args ...interface{}) (sql.Result, error)
+    TxQuery(tx *sql.Tx, query string, args ...interface{}) (*sql.Rows, error)
+    TxQueryRow(tx *sql.Tx, query string, args ...interface{}) *sql.Row
+    InsertReturningID(query string, args ...interface{}) (int, error)
+    Close() error
+
+    AddTask(title, description string, tags []string) (*models.Task, error)
+    AddTasks(tasks []models.Task) ([]models.Task, error)
+    GetTask(id int) (*models.Task, error)
+    GetAllTasks() ([]models.Task, error)
+    GetPaginatedTasks(offset, limit int) ([]models.Task, int, error)
+    GetFilteredTasks(offset, limit int, filter TaskFilter) ([]models.Task, int, error)
+    UpdateTask(id int, title, description string) error
+    DeleteTask(id int) error
+    DeleteAllTasks() error
+    ReorderTasks(ids []int) error
+    MoveTask(id int, beforeID, afterID *int) error
+    RebalancePositions() error
+    CountTasks() (int, error)
+    CheckTaskExists(id int) (bool, error)
+    GenerateDummyTasks(count int) error
+    GetLastTask() (*models.Task, error)
+    SetTaskStatus(id int, status string) error
+    PauseTask(id int) error
+    ResumeTask(id int) error
+    CompleteTask(id int) error
+
+    ImportTasks(body io.Reader, contentType string) (int, error)
+    ExportTasks(w io.Writer, format string) error
+}
+
+// dialect captures the handful of behaviors that differ between backends:
+// placeholder syntax, how to recover an inserted id, schema migrations,
+// and bulk-insert tuning. Everything else - the query logic in
+// tasks.go/importexport.go - is written once against sqlStore's "?"
+// placeholders and runs unchanged on either backend.
+type dialect interface {
+    driver() Driver
+    rebind(query string) string
+    insertReturningID(db *sql.DB, query string, args ...interface{}) (int, error)
+    migrate(db *sql.DB) error
+    tuneForBulkInsert(db *sql.DB) error
+}
+
+// sqlStore is the one Store implementation; it's backed by a dialect that
+// supplies everything that differs between SQLite and Postgres.
+type sqlStore struct {
+    db *sql.DB
+    d  dialect
+}
+
+// OpenFromEnv opens a Store using the DB_DRIVER env var ("sqlite", the
+// default, or "postgres") and DB_DSN for the connection string. DB_DSN
+// defaults to "./tasks.db" for sqlite.
+func OpenFromEnv() (Store, error) {
+    driver := Driver(os.Getenv("DB_DRIVER"))
+    if driver == "" {
+        driver = DriverSQLite
+    }
+
+    dsn := os.Getenv("DB_DSN")
+    if dsn == "" && driver == DriverSQLite {
+        dsn = "./tasks.db"
+    }
+
+    return Open(driver, dsn)
+}
+
+// Open opens a Store for the given driver and DSN and runs its migrations.
+func Open(driver Driver, dsn string) (Store, error) {
+    var d dialect
+    switch driver {
+    case DriverPostgres:
+        d = postgresDialect{}
+    case DriverSQLite:
+        d = sqliteDialect{}
+    default:
+        log.Fatalf("unknown DB_DRIVER %q, expected %q or %q", driver, DriverSQLite, DriverPostgres)
+    }
+
+    db, err := sql.Open(driverName(driver), dsn)
+    if err != nil {
+        return nil, err
+    }
+
+    s := &sqlStore{db: db, d: d}
+    if err := s.d.migrate(s.db); err != nil {
+        return nil, err
+    }
+    return s, nil
+}
+
+func driverName(driver Driver) string {
+    if driver == DriverPostgres {
+        return "postgres"
+    }
+    return "sqlite"
+}
+
+func (s *sqlStore) Close() error {
+    return s.db.Close()
+}
+
+func (s *sqlStore) rebind(query string) string {
+    return s.d.rebind(query)
+}
+
+func (s *sqlStore) Exec(query string, args ...interface{}) (sql.Result, error) {
+    return s.db.Exec(s.rebind(query), args...)
+}
+
+func (s *sqlStore) Query(query string, args ...interface{}) (*sql.Rows, error) {
+    return s.db.Query(s.rebind(query), args...)
+}
+
+func (s *sqlStore) QueryRow(query string, args ...interface{}) *sql.Row {
+    return s.db.QueryRow(s.rebind(query), args...)
+}
+
+func (s *sqlStore) Begin() (*sql.Tx, error) {
+    return s.db.Begin()
+}
+
+// TxExec runs query (rebound for the active driver) against an
+// in-progress transaction. Callers hold the *sql.Tx across several
+// statements, so this can't just go through sqlStore.Exec.
+func (s *sqlStore) TxExec(tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+    return tx.Exec(s.rebind(query), args...)
+}
+
+func (s *sqlStore) TxQuery(tx *sql.Tx, query string, args ...interface{}) (*sql.Rows, error) {
+    return tx.Query(s.rebind(query), args...)
+}
+
+func (s *sqlStore) TxQueryRow(tx *sql.Tx, query string, args ...interface{}) *sql.Row {
+    return tx.QueryRow(s.rebind(query), args...)
+}
+
+// InsertReturningID runs an INSERT and returns the new row's id. SQLite
+// supports LastInsertId; pq does not, so the Postgres dialect instead
+// gives the insert query a RETURNING id clause.
+func (s *sqlStore) InsertReturningID(query string, args ...interface{}) (int, error) {
+    return s.d.insertReturningID(s.db, s.rebind(query), args...)
+}
@@ -4,42 +4,109 @@ import (
     "log"
     "time"
 	"fmt"
+	"strings"
 	"database/sql"
+	"encoding/json"
 
     "task-api/models"
+    "task-api/utils/rank"
 )
 
-// AddTask inserts a new task into the database
-func AddTask(title, description string, position int) (*models.Task, error) {
-    query := `
-    INSERT INTO tasks (title, description, position, created_at, updated_at)
-    VALUES (?, ?, ?, ?, ?)
-    `
-    result, err := db.Exec(query, title, description, position, time.Now(), time.Now())
+const taskColumns = `id, title, description, position, tags, status, started_at, paused_at, completed_at, due_at, created_at, updated_at`
+
+// scanTask scans a single task row produced by a query selecting taskColumns.
+func scanTask(scanner interface{ Scan(...interface{}) error }, task *models.Task) error {
+    var tags sql.NullString
+    if err := scanner.Scan(
+        &task.ID, &task.Title, &task.Description, &task.Position, &tags, &task.Status,
+        &task.StartedAt, &task.PausedAt, &task.CompletedAt, &task.DueAt,
+        &task.CreatedAt, &task.UpdatedAt,
+    ); err != nil {
+        return err
+    }
+
+    if tags.Valid && tags.String != "" {
+        if err := json.Unmarshal([]byte(tags.String), &task.Tags); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// encodeTags marshals a task's tags for storage in the tags TEXT column.
+func encodeTags(tags []string) (string, error) {
+    if len(tags) == 0 {
+        return "", nil
+    }
+    b, err := json.Marshal(tags)
+    if err != nil {
+        return "", err
+    }
+    return string(b), nil
+}
+
+// getLastPosition returns the rank key of the last task in position order,
+// or "" if the table is empty.
+func (s *sqlStore) getLastPosition() (string, error) {
+    var position string
+    err := s.QueryRow("SELECT position FROM tasks ORDER BY position DESC LIMIT 1").Scan(&position)
+    if err == sql.ErrNoRows {
+        return "", nil
+    }
+    if err != nil {
+        return "", err
+    }
+    return position, nil
+}
+
+// AddTask inserts a new task at the end of the list, assigning it a rank
+// key immediately after the current last task.
+func (s *sqlStore) AddTask(title, description string, tags []string) (*models.Task, error) {
+    lastPosition, err := s.getLastPosition()
     if err != nil {
         return nil, err
     }
+    position := rank.Between(lastPosition, "")
 
-    id, err := result.LastInsertId()
+    encodedTags, err := encodeTags(tags)
     if err != nil {
         return nil, err
     }
 
+    query := `
+    INSERT INTO tasks (title, description, position, tags, status, created_at, updated_at)
+    VALUES (?, ?, ?, ?, ?, ?, ?)
+    `
+    now := time.Now()
+    id, err := s.InsertReturningID(query, title, description, position, encodedTags, models.StatusPending, now, now)
+    if err != nil {
+        return nil, err
+    }
+
+    if len(position) > rank.MaxKeyLength {
+        if err := s.RebalancePositions(); err != nil {
+            return nil, err
+        }
+        return s.GetTask(id)
+    }
+
     return &models.Task{
-        ID:          int(id),
+        ID:          id,
         Title:       title,
         Description: description,
         Position:    position,
-        CreatedAt:   time.Now(),
-        UpdatedAt:   time.Now(),
+        Tags:        tags,
+        Status:      models.StatusPending,
+        CreatedAt:   now,
+        UpdatedAt:   now,
     }, nil
 }
 
-func AddTasks(tasks []models.Task) ([]models.Task, error) {
+func (s *sqlStore) AddTasks(tasks []models.Task) ([]models.Task, error) {
     var createdTasks []models.Task
 
     for _, task := range tasks {
-        newTask, err := AddTask(task.Title, task.Description, task.Position)
+        newTask, err := s.AddTask(task.Title, task.Description, task.Tags)
         if err != nil {
             return nil, err
         }
@@ -49,14 +116,27 @@ func AddTasks(tasks []models.Task) ([]models.Task, error) {
     return createdTasks, nil
 }
 
+// GetTask retrieves a single task by ID.
+func (s *sqlStore) GetTask(id int) (*models.Task, error) {
+    var task models.Task
+    err := scanTask(s.QueryRow("SELECT "+taskColumns+" FROM tasks WHERE id = ?", id), &task)
+    if err == sql.ErrNoRows {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &task, nil
+}
+
 // GetAllTasks retrieves all tasks sorted by position
-func GetAllTasks() ([]models.Task, error) {
+func (s *sqlStore) GetAllTasks() ([]models.Task, error) {
     query := `
-    SELECT id, title, description, position, created_at, updated_at
+    SELECT ` + taskColumns + `
     FROM tasks
     ORDER BY position ASC
     `
-    rows, err := db.Query(query)
+    rows, err := s.Query(query)
     if err != nil {
         return nil, err
     }
@@ -65,8 +145,7 @@ func GetAllTasks() ([]models.Task, error) {
     var tasks []models.Task
     for rows.Next() {
         var task models.Task
-        err := rows.Scan(&task.ID, &task.Title, &task.Description, &task.Position, &task.CreatedAt, &task.UpdatedAt)
-        if err != nil {
+        if err := scanTask(rows, &task); err != nil {
             log.Println(err)
             continue
         }
@@ -76,25 +155,25 @@ func GetAllTasks() ([]models.Task, error) {
 }
 
 // GetPaginatedTasks retrieves a subset of tasks and the total count
-func GetPaginatedTasks(offset, limit int) ([]models.Task, int, error) {
+func (s *sqlStore) GetPaginatedTasks(offset, limit int) ([]models.Task, int, error) {
     var tasks []models.Task
     var totalCount int
 
     // Count total tasks
     countQuery := "SELECT COUNT(*) FROM tasks"
-    err := db.QueryRow(countQuery).Scan(&totalCount)
+    err := s.QueryRow(countQuery).Scan(&totalCount)
     if err != nil {
         return nil, 0, err
     }
 
     // Fetch paginated tasks
     query := `
-    SELECT id, title, description, position, created_at, updated_at
+    SELECT ` + taskColumns + `
     FROM tasks
     ORDER BY position ASC
     LIMIT ? OFFSET ?
     `
-    rows, err := db.Query(query, limit, offset)
+    rows, err := s.Query(query, limit, offset)
     if err != nil {
         return nil, 0, err
     }
@@ -102,8 +181,7 @@ func GetPaginatedTasks(offset, limit int) ([]models.Task, int, error) {
 
     for rows.Next() {
         var task models.Task
-        err := rows.Scan(&task.ID, &task.Title, &task.Description, &task.Position, &task.CreatedAt, &task.UpdatedAt)
-        if err != nil {
+        if err := scanTask(rows, &task); err != nil {
             return nil, 0, err
         }
         tasks = append(tasks, task)
@@ -112,29 +190,97 @@ func GetPaginatedTasks(offset, limit int) ([]models.Task, int, error) {
     return tasks, totalCount, nil
 }
 
-// UpdateTask updates an existing task
-func UpdateTask(id int, title, description string, position int) error {
+// TaskFilter narrows down GetFilteredTasks results. Zero values mean "no filter".
+type TaskFilter struct {
+    Status    string
+    DueBefore *time.Time
+    Query     string
+}
+
+// GetFilteredTasks retrieves a subset of tasks matching the given filter,
+// along with the total count of matching rows, using a predicate on the
+// indexed status column, a due_at comparison (due_at has no index of its
+// own) and a LIKE search over title/description.
+func (s *sqlStore) GetFilteredTasks(offset, limit int, filter TaskFilter) ([]models.Task, int, error) {
+    var conditions []string
+    var args []interface{}
+
+    if filter.Status != "" {
+        conditions = append(conditions, "status = ?")
+        args = append(args, filter.Status)
+    }
+    if filter.DueBefore != nil {
+        conditions = append(conditions, "due_at IS NOT NULL AND due_at < ?")
+        args = append(args, filter.DueBefore)
+    }
+    if filter.Query != "" {
+        conditions = append(conditions, "(title LIKE ? OR description LIKE ?)")
+        like := "%" + filter.Query + "%"
+        args = append(args, like, like)
+    }
+
+    where := ""
+    if len(conditions) > 0 {
+        where = "WHERE " + strings.Join(conditions, " AND ")
+    }
+
+    var totalCount int
+    countQuery := "SELECT COUNT(*) FROM tasks " + where
+    if err := s.QueryRow(countQuery, args...).Scan(&totalCount); err != nil {
+        return nil, 0, err
+    }
+
+    query := "SELECT " + taskColumns + " FROM tasks " + where + " ORDER BY position ASC LIMIT ? OFFSET ?"
+    rows, err := s.Query(query, append(args, limit, offset)...)
+    if err != nil {
+        return nil, 0, err
+    }
+    defer rows.Close()
+
+    var tasks []models.Task
+    for rows.Next() {
+        var task models.Task
+        if err := scanTask(rows, &task); err != nil {
+            return nil, 0, err
+        }
+        tasks = append(tasks, task)
+    }
+
+    return tasks, totalCount, nil
+}
+
+// UpdateTask updates an existing task's title and description. Position is
+// changed separately, via MoveTask.
+func (s *sqlStore) UpdateTask(id int, title, description string) error {
     query := `
     UPDATE tasks
-    SET title = ?, description = ?, position = ?, updated_at = ?
+    SET title = ?, description = ?, updated_at = ?
     WHERE id = ?
     `
-    _, err := db.Exec(query, title, description, position, time.Now(), id)
+    _, err := s.Exec(query, title, description, time.Now(), id)
     return err
 }
 
 // DeleteTask deletes a task by ID
-func DeleteTask(id int) error {
+func (s *sqlStore) DeleteTask(id int) error {
     query := `
     DELETE FROM tasks WHERE id = ?
     `
-    _, err := db.Exec(query, id)
+    _, err := s.Exec(query, id)
     return err
 }
 
-// ReorderTasks updates the positions of multiple tasks
-func ReorderTasks(updatedTasks []models.Task) error {
-    tx, err := db.Begin()
+// ReorderTasks takes task IDs in their desired new order and assigns each
+// one a freshly rebalanced rank key, so the whole list gets short, evenly
+// spaced keys in one pass instead of drifting longer over time.
+func (s *sqlStore) ReorderTasks(ids []int) error {
+    if len(ids) == 0 {
+        return fmt.Errorf("invalid task data: at least one ID is required")
+    }
+
+    keys := rank.Rebalance(len(ids))
+
+    tx, err := s.Begin()
     if err != nil {
         return err
     }
@@ -144,13 +290,13 @@ func ReorderTasks(updatedTasks []models.Task) error {
     SET position = ?, updated_at = ?
     WHERE id = ?
     `
-    for _, task := range updatedTasks {
-        if task.ID == 0 || task.Position <= 0 {
+    now := time.Now()
+    for i, id := range ids {
+        if id == 0 {
             tx.Rollback()
-            return fmt.Errorf("invalid task data: ID and position are required")
+            return fmt.Errorf("invalid task data: ID is required")
         }
-        _, err := tx.Exec(query, task.Position, time.Now(), task.ID)
-        if err != nil {
+        if _, err := s.TxExec(tx, query, keys[i], now, id); err != nil {
             tx.Rollback()
             return err
         }
@@ -159,11 +305,104 @@ func ReorderTasks(updatedTasks []models.Task) error {
     return tx.Commit()
 }
 
+// MoveTask assigns task id a new rank key strictly between beforeID's and
+// afterID's positions, writing only that one row. Either bound may be nil
+// to mean "move to the very start/end of the list".
+func (s *sqlStore) MoveTask(id int, beforeID, afterID *int) error {
+    var beforePos, afterPos string
+
+    if beforeID != nil {
+        if err := s.QueryRow("SELECT position FROM tasks WHERE id = ?", *beforeID).Scan(&beforePos); err != nil {
+            return err
+        }
+    }
+    if afterID != nil {
+        if err := s.QueryRow("SELECT position FROM tasks WHERE id = ?", *afterID).Scan(&afterPos); err != nil {
+            return err
+        }
+    }
+
+    position := rank.Between(beforePos, afterPos)
+    _, err := s.Exec("UPDATE tasks SET position = ?, updated_at = ? WHERE id = ?", position, time.Now(), id)
+    if err != nil {
+        return err
+    }
+
+    if len(position) > rank.MaxKeyLength {
+        return s.RebalancePositions()
+    }
+    return nil
+}
+
+// rebalancePositions reassigns every task an evenly spaced rank key,
+// preserving the current order. Intended to be called once keys produced
+// by repeated Between calls grow past rank.MaxKeyLength. tx may be nil, in
+// which case the statements run directly against the Store instead of an
+// existing transaction. ImportTasks and GenerateDummyTasks fold a rebalance
+// into their own transaction; RebalancePositions opens one of its own.
+func (s *sqlStore) rebalancePositions(tx *sql.Tx) error {
+    var rows *sql.Rows
+    var err error
+    if tx != nil {
+        rows, err = s.TxQuery(tx, "SELECT id FROM tasks ORDER BY position ASC")
+    } else {
+        rows, err = s.Query("SELECT id FROM tasks ORDER BY position ASC")
+    }
+    if err != nil {
+        return err
+    }
+    var ids []int
+    for rows.Next() {
+        var id int
+        if err := rows.Scan(&id); err != nil {
+            rows.Close()
+            return err
+        }
+        ids = append(ids, id)
+    }
+    rows.Close()
+
+    keys := rank.Rebalance(len(ids))
+    now := time.Now()
+    for i, id := range ids {
+        if tx != nil {
+            _, err = s.TxExec(tx, "UPDATE tasks SET position = ?, updated_at = ? WHERE id = ?", keys[i], now, id)
+        } else {
+            _, err = s.Exec("UPDATE tasks SET position = ?, updated_at = ? WHERE id = ?", keys[i], now, id)
+        }
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// RebalancePositions reassigns every task an evenly spaced rank key in its
+// own transaction. See rebalancePositions for the underlying logic.
+func (s *sqlStore) RebalancePositions() error {
+    tx, err := s.Begin()
+    if err != nil {
+        return err
+    }
+    if err := s.rebalancePositions(tx); err != nil {
+        tx.Rollback()
+        return err
+    }
+    return tx.Commit()
+}
+
+// CountTasks returns the total number of tasks, for metrics reporting.
+func (s *sqlStore) CountTasks() (int, error) {
+    var count int
+    err := s.QueryRow("SELECT COUNT(*) FROM tasks").Scan(&count)
+    return count, err
+}
+
 // CheckTaskExists checks if a task with the given ID exists
-func CheckTaskExists(id int) (bool, error) {
+func (s *sqlStore) CheckTaskExists(id int) (bool, error) {
     var count int
     query := "SELECT COUNT(*) FROM tasks WHERE id = ?"
-    err := db.QueryRow(query, id).Scan(&count)
+    err := s.QueryRow(query, id).Scan(&count)
     if err != nil {
         return false, err
     }
@@ -171,108 +410,131 @@ func CheckTaskExists(id int) (bool, error) {
 }
 
 // DeleteAllTasks deletes all tasks from the database
-func DeleteAllTasks() error {
+func (s *sqlStore) DeleteAllTasks() error {
     query := "DELETE FROM tasks"
-    _, err := db.Exec(query)
+    _, err := s.Exec(query)
     return err
 }
 
 // GenerateDummyTasks inserts a specified number of dummy tasks into the database
-func GenerateDummyTasks(count int) error {
+func (s *sqlStore) GenerateDummyTasks(count int) error {
     log.Printf("Starting to generate %d dummy tasks", count)
 
-    lastTask, err := GetLastTask()
+    lastTask, err := s.GetLastTask()
     if err != nil {
         log.Printf("Error fetching last task: %v", err)
         return err
     }
 
     startID := 1
-    startPosition := 1
+    lastPosition := ""
     if lastTask != nil {
         startID = lastTask.ID + 1
-        startPosition = lastTask.Position + 1
+        lastPosition = lastTask.Position
     }
 
-    log.Printf("Starting ID: %d, Starting Position: %d", startID, startPosition)
+    log.Printf("Starting ID: %d, after position %q", startID, lastPosition)
 
-    // Optimize SQLite settings
-    _, err = db.Exec("PRAGMA synchronous = OFF")
-    if err != nil {
-        log.Printf("Error setting PRAGMA synchronous = OFF: %v", err)
-        return err
-    }
-    _, err = db.Exec("PRAGMA journal_mode = MEMORY")
-    if err != nil {
-        log.Printf("Error setting PRAGMA journal_mode = MEMORY: %v", err)
-        return err
-    }
-    _, err = db.Exec("PRAGMA cache_size = -10000") // Allocate 10MB of cache
-    if err != nil {
-        log.Printf("Error setting PRAGMA cache_size = -10000: %v", err)
+    if err := s.d.tuneForBulkInsert(s.db); err != nil {
+        log.Printf("Error tuning database for bulk insert: %v", err)
         return err
     }
 
-    stmt, err := db.Prepare(`
-        INSERT INTO tasks (title, description, position, created_at, updated_at)
-        VALUES (?, ?, ?, ?, ?)
-    `)
-    if err != nil {
-        log.Printf("Error preparing INSERT statement: %v", err)
-        return err
-    }
-    defer stmt.Close()
+    query := `
+        INSERT INTO tasks (title, description, position, status, tags, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+    `
 
-    batchSize := 1000 
+    // Inserts are batched into one transaction per batchSize rows, committed
+    // as each batch completes, rather than left open via defer: a function-
+    // scoped defer would hold every batch's transaction open until
+    // GenerateDummyTasks returns, giving no real atomicity and pinning a DB
+    // connection per batch for the whole run.
+    batchSize := 1000
+    position := lastPosition
+    var tx *sql.Tx
     for i := 0; i < count; i++ {
         id := startID + i
-        position := startPosition + i
-        title := fmt.Sprintf("Task %d", id)
-        description := fmt.Sprintf("Description for task %d", id)
-        createdAt := time.Now()
-        updatedAt := createdAt
 
         if i%batchSize == 0 {
+            if tx != nil {
+                log.Println("Committing transaction")
+                if err := tx.Commit(); err != nil {
+                    log.Printf("Error committing transaction: %v", err)
+                    return err
+                }
+            }
             log.Printf("Starting batch %d-%d", id, id+batchSize-1)
-            tx, txErr := db.Begin()
-            if txErr != nil {
-                log.Printf("Error starting transaction: %v", txErr)
-                return txErr
+            tx, err = s.Begin()
+            if err != nil {
+                log.Printf("Error starting transaction: %v", err)
+                return err
             }
-            defer func() {
-                if txErr != nil {
-                    log.Printf("Rolling back transaction due to error: %v", txErr)
-                    tx.Rollback()
-                } else {
-                    log.Println("Committing transaction")
-                    tx.Commit()
-                }
-            }()
         }
 
-        _, execErr := stmt.Exec(title, description, position, createdAt, updatedAt)
-        if execErr != nil {
+        position = rank.Between(position, "")
+        title := fmt.Sprintf("Task %d", id)
+        description := fmt.Sprintf("Description for task %d", id)
+        createdAt := time.Now()
+
+        if _, execErr := s.TxExec(tx, query, title, description, position, models.StatusPending, "", createdAt, createdAt); execErr != nil {
             log.Printf("Error inserting task %d: %v", id, execErr)
+            tx.Rollback()
             return execErr
         }
+
+        // Appending at the end grows the rank key by one character per row;
+        // rebalance as soon as that crosses MaxKeyLength instead of waiting
+        // for the whole run to finish; left unchecked, Between's per-call
+        // cost and the position column's size both grow with count.
+        if len(position) > rank.MaxKeyLength {
+            if err := s.rebalancePositions(tx); err != nil {
+                log.Printf("Error rebalancing positions during generation: %v", err)
+                tx.Rollback()
+                return err
+            }
+            if err := s.TxQueryRow(tx, "SELECT position FROM tasks ORDER BY position DESC LIMIT 1").Scan(&position); err != nil {
+                log.Printf("Error refetching position after rebalance: %v", err)
+                tx.Rollback()
+                return err
+            }
+        }
+    }
+
+    if tx != nil {
+        log.Println("Committing transaction")
+        if err := tx.Commit(); err != nil {
+            log.Printf("Error committing final transaction: %v", err)
+            return err
+        }
     }
 
     log.Printf("Successfully inserted %d dummy tasks", count)
+
+    // Repeatedly appending at the end grows the rank key by one character
+    // per row, so flatten everything back down to short, evenly spaced keys.
+    if len(position) > rank.MaxKeyLength {
+        if err := s.RebalancePositions(); err != nil {
+            log.Printf("Error rebalancing positions after generation: %v", err)
+            return err
+        }
+    }
+
     return nil
 }
 
 // GetLastTask retrieves the last inserted task
-func GetLastTask() (*models.Task, error) {
+func (s *sqlStore) GetLastTask() (*models.Task, error) {
     var task models.Task
     query := `
-    SELECT id, title, description, position, created_at, updated_at
+    SELECT ` + taskColumns + `
     FROM tasks
     ORDER BY id DESC
     LIMIT 1
     `
-    err := db.QueryRow(query).Scan(&task.ID, &task.Title, &task.Description, &task.Position, &task.CreatedAt, &task.UpdatedAt)
+    err := scanTask(s.QueryRow(query), &task)
     if err == sql.ErrNoRows {
-        return nil, nil 
+        return nil, nil
     }
     if err != nil {
         return nil, err
@@ -280,13 +542,66 @@ func GetLastTask() (*models.Task, error) {
     return &task, nil
 }
 
-// CheckPositionExists checks if a position already exists in the database
-func CheckPositionExists(position int) (bool, error) {
-    var count int
-    query := "SELECT COUNT(*) FROM tasks WHERE position = ?"
-    err := db.QueryRow(query, position).Scan(&count)
-    if err != nil {
-        return false, err
+// legalTransitions enumerates which status a task may move to from its
+// current status. Resuming a completed task, for example, is not allowed.
+var legalTransitions = map[string][]string{
+    models.StatusPending:   {models.StatusActive, models.StatusArchived},
+    models.StatusActive:    {models.StatusPaused, models.StatusCompleted, models.StatusArchived},
+    models.StatusPaused:    {models.StatusActive, models.StatusCompleted, models.StatusArchived},
+    models.StatusCompleted: {models.StatusArchived},
+    models.StatusArchived:  {},
+}
+
+func canTransition(from, to string) bool {
+    for _, allowed := range legalTransitions[from] {
+        if allowed == to {
+            return true
+        }
     }
-    return count > 0, nil
-}
\ No newline at end of file
+    return false
+}
+
+// SetTaskStatus transitions a task to the given status, enforcing the
+// lifecycle rules in legalTransitions and stamping the matching timestamp
+// column. Returns an error if the transition is not legal.
+func (s *sqlStore) SetTaskStatus(id int, status string) error {
+    var current string
+    if err := s.QueryRow("SELECT status FROM tasks WHERE id = ?", id).Scan(&current); err != nil {
+        return err
+    }
+
+    if !canTransition(current, status) {
+        return fmt.Errorf("cannot transition task %d from %q to %q", id, current, status)
+    }
+
+    now := time.Now()
+    switch status {
+    case models.StatusActive:
+        _, err := s.Exec("UPDATE tasks SET status = ?, started_at = ?, updated_at = ? WHERE id = ?", status, now, now, id)
+        return err
+    case models.StatusPaused:
+        _, err := s.Exec("UPDATE tasks SET status = ?, paused_at = ?, updated_at = ? WHERE id = ?", status, now, now, id)
+        return err
+    case models.StatusCompleted:
+        _, err := s.Exec("UPDATE tasks SET status = ?, completed_at = ?, updated_at = ? WHERE id = ?", status, now, now, id)
+        return err
+    default:
+        _, err := s.Exec("UPDATE tasks SET status = ?, updated_at = ? WHERE id = ?", status, now, id)
+        return err
+    }
+}
+
+// PauseTask moves a task from active to paused.
+func (s *sqlStore) PauseTask(id int) error {
+    return s.SetTaskStatus(id, models.StatusPaused)
+}
+
+// ResumeTask moves a task from paused back to active.
+func (s *sqlStore) ResumeTask(id int) error {
+    return s.SetTaskStatus(id, models.StatusActive)
+}
+
+// CompleteTask marks a task as completed.
+func (s *sqlStore) CompleteTask(id int) error {
+    return s.SetTaskStatus(id, models.StatusCompleted)
+}